@@ -1,22 +1,37 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/cilium/ebpf/btf"
 	"os"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 )
 
 var reader *btf.Spec
-var targetTypes = flag.String("target", "", "export specific target types, split by ',', eg: 'struct:a_name,b_name'")
+var targetTypes = flag.String("target", "", "export specific target types, split by ',', eg: 'struct:a_name,b_name'. Supports glob patterns in the name part, eg: 'struct:task_*'")
+var targetRegex = flag.String("target-regex", "", "select types, of any kind, whose name matches this regex, eg: '^sk_.*'")
+var kindFilter = flag.String("kind", "", "filter the selected types by kind, split by ',', eg: 'struct,union'. With no --target/--target-regex, selects all types of these kinds")
+var isReverseDeps = flag.Bool("reverse-deps", false, "also include every type that (transitively) references a selected type")
 var isDereference = flag.Bool("dereference", false, "skip qualifiers and typedefs")
 var isAsMap = flag.Bool("as-map", false, "export the types containing child elements (struct,union,enum) as a map")
+var isFlattenAnon = flag.Bool("flatten-anon", false, "inline anonymous nested struct/union members into the parent, with adjusted offsets")
 var isVerbose = flag.Bool("verbose", false, "display working progress")
+var inputFormat = flag.String("input-format", "auto", "input format: elf|raw|json, auto sniffs the BTF magic. json is rejected with an error: a JSON dump cannot be loaded back into a BTF spec")
+var baseFile = flag.String("base", "", "base BTF file (eg. vmlinux) used to resolve a split/module BTF given with --input-format=raw")
+var outputFormat = flag.String("output-format", "json", "output format: json|raw-btf|c-header")
+var diffAgainst = flag.String("diff", "", "diff this BTF against another BTF file, matching types by kind + name (essential name for struct/union/enum/enum64)")
+var diffFormat = flag.String("diff-format", "human", "diff report format: json|human")
 var fileName string
-var btfFile *os.File
+
+// btfRawMagic is BTF_MAGIC (0xeB9F) as it appears in the first two bytes of a raw BTF blob.
+var btfRawMagic = []byte{0x9f, 0xeb}
 
 func main() {
 	var err error
@@ -32,22 +47,78 @@ func main() {
 		return
 	}
 	fileName = flag.Arg(0)
-	btfFile, err = os.Open(fileName)
-	defer btfFile.Close()
+	reader, err = LoadInput(fileName, *inputFormat, *baseFile)
 	if err != nil {
 		panic(err)
 	}
-	reader, err = btf.LoadSpecFromReader(btfFile)
+	if *diffAgainst != "" {
+		other, err := LoadInput(*diffAgainst, *inputFormat, "")
+		if err != nil {
+			panic(err)
+		}
+		oldIDs := ReadAllBTFTypeByID()
+		newIDs := readAllTypesForSpec(other)
+		DumpDiff(computeDiff(oldIDs, newIDs))
+		return
+	}
+	ids := QueryTypes()
+	switch *outputFormat {
+	case "json":
+		if !hasTypeQuery() {
+			DumpAll(ReadAllBTFType())
+		} else {
+			DumpAll(ids)
+		}
+	case "raw-btf":
+		DumpRawBTF(ids)
+	case "c-header":
+		DumpCHeader(ids)
+	default:
+		panic(fmt.Sprintf("output format must be one of json, raw-btf, c-header, got %q", *outputFormat))
+	}
+}
+
+// LoadInput opens fileName and loads it as a *btf.Spec. format selects how the
+// file is interpreted: "elf" for an ELF object carrying a .BTF section, "raw"
+// for a standalone BTF blob (magic 0xeB9F), or "auto" to sniff the magic.
+// base, when non-empty and the input is raw, is loaded first and used to
+// resolve a split BTF (eg. a kernel module BTF against its vmlinux); it is
+// ignored for ELF input.
+func LoadInput(fileName string, format string, base string) (*btf.Spec, error) {
+	data, err := os.ReadFile(fileName)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	if *targetTypes == "" {
-		types := ReadAllBTFType()
-		DumpAll(types)
-	} else {
-		targets := strings.Split(*targetTypes, ",")
-		types := WalkForTargetTypes(targets)
-		DumpAll(types)
+	if format == "auto" {
+		if len(data) >= 2 && bytes.Equal(data[:2], btfRawMagic) {
+			format = "raw"
+		} else {
+			format = "elf"
+		}
+	}
+	switch format {
+	case "raw":
+		// LoadSpecFromReader already sniffs and parses a standalone BTF blob
+		// (there is no separate btf.LoadRawSpec); split BTF against a base
+		// goes through LoadSplitSpecFromReader instead.
+		if base != "" {
+			baseData, err := os.ReadFile(base)
+			if err != nil {
+				return nil, err
+			}
+			baseSpec, err := btf.LoadSpecFromReader(bytes.NewReader(baseData))
+			if err != nil {
+				return nil, err
+			}
+			return btf.LoadSplitSpecFromReader(bytes.NewReader(data), baseSpec)
+		}
+		return btf.LoadSpecFromReader(bytes.NewReader(data))
+	case "elf":
+		return btf.LoadSpecFromReader(bytes.NewReader(data))
+	case "json":
+		return nil, fmt.Errorf("input-format=json cannot be loaded back into a BTF spec")
+	default:
+		return nil, fmt.Errorf("input format must be one of elf, raw, json, got %q", format)
 	}
 }
 
@@ -87,7 +158,7 @@ func NameToBTFType(name string) btf.Type {
 	case "Float":
 		return &btf.Float{}
 	default:
-		panic("type must be one of void, int, pointer, array, struct, union, enum, fwd, typedef, volatile, restrict, func, funcproto, var, Datasec, Float")
+		panic("type must be one of void, int, pointer, array, struct, union, enum, fwd, typedef, volatile, restrict, func, funcproto, var, Datasec, Float (--target also accepts decl_tag, type_tag and enum64, which have no exported concrete type of their own in cilium/ebpf and are matched by scanning every type of that kind instead of through this lookup)")
 	}
 }
 
@@ -104,31 +175,23 @@ func DumpAll(types interface{}) {
 	outputFile.Close()
 }
 
+// WalkForTargetTypes resolves targetTypes (the "kind:name" / "name" syntax,
+// with glob support in the name part) and walks their forward dependencies.
 func WalkForTargetTypes(targetTypes []string) map[uint32]BTFType {
+	return WalkFromSeeds(resolveGlobTargets(targetTypes))
+}
+
+// WalkFromSeeds does a BFS over seedIDs' forward dependencies (via
+// BTFType.GetDependencies), returning every type reachable from them.
+func WalkFromSeeds(seedIDs []uint32) map[uint32]BTFType {
 	results := make(map[uint32]BTFType, 0)
-	queue := make([]uint32, 0)
-	for _, name := range targetTypes {
-		var found btf.Type
-		name = strings.TrimSpace(name)
-		a, b, success := strings.Cut(name, ":")
-		if success {
-			found = NameToBTFType(a)
-			if err := reader.TypeByName(b, &found); err != nil {
-				panic(err)
-			}
-		} else {
-			var err error
-			found, err = reader.AnyTypeByName(name)
-			if err != nil {
-				panic(err)
-			}
-		}
-		foundID, _ := reader.TypeID(found)
-		queue = append(queue, uint32(foundID))
-	}
+	queue := append(make([]uint32, 0, len(seedIDs)), seedIDs...)
 	for len(queue) != 0 {
 		i := queue[0]
 		queue = queue[1:]
+		if _, ok := results[i]; ok {
+			continue
+		}
 		currentType, _ := reader.TypeByID(btf.TypeID(i))
 		convertedType := BTFTypeParser(currentType)
 		results[i] = convertedType
@@ -142,6 +205,251 @@ func WalkForTargetTypes(targetTypes []string) map[uint32]BTFType {
 	return results
 }
 
+// hasTypeQuery reports whether any of --target, --target-regex or --kind
+// narrows the selection; with none set, the whole spec is selected.
+func hasTypeQuery() bool {
+	return *targetTypes != "" || *targetRegex != "" || *kindFilter != ""
+}
+
+// QueryTypes resolves --target, --target-regex and --kind into a seed set
+// (--kind narrows the other two when combined with them, otherwise it
+// selects every type of the given kinds on its own), optionally grows that
+// set to include every referencing type via --reverse-deps, then walks
+// forward dependencies so the result is self-contained.
+func QueryTypes() map[uint32]BTFType {
+	if !hasTypeQuery() {
+		return ReadAllBTFTypeByID()
+	}
+
+	var seedIDs []uint32
+	haveSeeds := false
+	if *targetTypes != "" {
+		seedIDs = append(seedIDs, resolveGlobTargets(strings.Split(*targetTypes, ","))...)
+		haveSeeds = true
+	}
+	if *targetRegex != "" {
+		seedIDs = append(seedIDs, resolveRegexTargets(*targetRegex)...)
+		haveSeeds = true
+	}
+	if *kindFilter != "" {
+		kinds := make(map[string]bool)
+		for _, k := range strings.Split(*kindFilter, ",") {
+			kinds[strings.TrimSpace(k)] = true
+		}
+		kindIDs := resolveKindTargets(kinds)
+		if haveSeeds {
+			seedIDs = intersectIDs(seedIDs, kindIDs)
+		} else {
+			seedIDs = kindIDs
+		}
+	}
+
+	seedIDs = dedupeIDs(seedIDs)
+	if *isReverseDeps {
+		seedIDs = dedupeIDs(append(seedIDs, reverseDepsClosure(seedIDs)...))
+	}
+	return WalkFromSeeds(seedIDs)
+}
+
+// resolveGlobTargets resolves the "kind:name" / "name" syntax used by
+// --target. A glob pattern (*, ?, [...]) in the name part matches every type
+// of that kind whose name matches, instead of requiring a unique name.
+func resolveGlobTargets(targetTypes []string) []uint32 {
+	ids := make([]uint32, 0, len(targetTypes))
+	for _, name := range targetTypes {
+		name = strings.TrimSpace(name)
+		kind, namePattern, hasKind := strings.Cut(name, ":")
+		if !hasKind {
+			found, err := reader.AnyTypeByName(name)
+			if err != nil {
+				panic(err)
+			}
+			id, _ := reader.TypeID(found)
+			ids = append(ids, uint32(id))
+			continue
+		}
+		// decl_tag/type_tag have no exported concrete type to hand to
+		// reader.TypeByName, and enum64 shares *btf.Enum with plain enum (BTF
+		// only distinguishes them by Size), so all three can only be
+		// selected through the kind-filtered scan below, even for an exact
+		// name.
+		if isGlobPattern(namePattern) || kind == "decl_tag" || kind == "type_tag" || kind == "enum64" {
+			ids = append(ids, findTypesByKind(kind, func(typeName string) bool {
+				if isGlobPattern(namePattern) {
+					ok, err := path.Match(namePattern, typeName)
+					if err != nil {
+						panic(err)
+					}
+					return ok
+				}
+				return typeName == namePattern
+			})...)
+			continue
+		}
+		found := NameToBTFType(kind)
+		if err := reader.TypeByName(namePattern, &found); err != nil {
+			panic(err)
+		}
+		id, _ := reader.TypeID(found)
+		ids = append(ids, uint32(id))
+	}
+	return ids
+}
+
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// resolveRegexTargets selects every named type, of any kind, whose name
+// matches pattern.
+func resolveRegexTargets(pattern string) []uint32 {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return findTypesByKind("", func(typeName string) bool { return re.MatchString(typeName) })
+}
+
+// resolveKindTargets selects every type whose kind (as used by --target's
+// "kind:name" syntax) is in kinds, regardless of name.
+func resolveKindTargets(kinds map[string]bool) []uint32 {
+	iter := reader.Iterate()
+	ids := make([]uint32, 0)
+	for iter.Next() {
+		parsed := BTFTypeParser(iter.Type)
+		if parsed == nil || !kinds[parsed.GetTypeName()] {
+			continue
+		}
+		id, _ := reader.TypeID(iter.Type)
+		ids = append(ids, uint32(id))
+	}
+	return ids
+}
+
+// findTypesByKind iterates every type in the spec, optionally restricted to
+// kind (the --target "kind:name" string, or "" for any kind), and selects
+// those whose name satisfies nameMatches.
+func findTypesByKind(kind string, nameMatches func(string) bool) []uint32 {
+	iter := reader.Iterate()
+	ids := make([]uint32, 0)
+	for iter.Next() {
+		parsed := BTFTypeParser(iter.Type)
+		if parsed == nil {
+			continue
+		}
+		if kind != "" && parsed.GetTypeName() != kind {
+			continue
+		}
+		name := btfTypeName(parsed)
+		if name == "" || !nameMatches(name) {
+			continue
+		}
+		id, _ := reader.TypeID(iter.Type)
+		ids = append(ids, uint32(id))
+	}
+	return ids
+}
+
+// btfTypeName extracts the BTF name of a parsed type, or "" for kinds that
+// have none (eg. pointers, arrays, qualifiers).
+func btfTypeName(t BTFType) string {
+	switch v := t.(type) {
+	case *BTFInt:
+		return v.Name
+	case *BTFFloat:
+		return v.Name
+	case *BTFStruct:
+		return v.Name
+	case *BTFUnion:
+		return v.Name
+	case *BTFEnum:
+		return v.Name
+	case *BTFEnum64:
+		return v.Name
+	case *BTFFwd:
+		return v.Name
+	case *BTFTypeDef:
+		return v.Name
+	case *BTFFunc:
+		return v.Name
+	case *BTFVar:
+		return v.Name
+	case *BTFDatasec:
+		return v.Name
+	case *BTFDeclTag:
+		return v.Name
+	case *BTFTypeTag:
+		return v.Name
+	default:
+		return ""
+	}
+}
+
+func intersectIDs(a []uint32, b []uint32) []uint32 {
+	bSet := make(map[uint32]bool, len(b))
+	for _, id := range b {
+		bSet[id] = true
+	}
+	out := make([]uint32, 0, len(a))
+	for _, id := range a {
+		if bSet[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func dedupeIDs(ids []uint32) []uint32 {
+	seen := make(map[uint32]bool, len(ids))
+	out := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// buildReferrerIndex inverts every type's GetDependencies() into a
+// map[uint32][]uint32 of referrers: index[id] lists every type that
+// references id.
+func buildReferrerIndex() map[uint32][]uint32 {
+	all := ReadAllBTFTypeByID()
+	index := make(map[uint32][]uint32)
+	for id, t := range all {
+		for _, dep := range t.GetDependencies() {
+			index[dep] = append(index[dep], id)
+		}
+	}
+	return index
+}
+
+// reverseDepsClosure returns every type that transitively references one of
+// seedIDs (eg. a struct embedding it, a funcproto taking it as a parameter,
+// or a datasec referencing it), for --reverse-deps impact analysis.
+func reverseDepsClosure(seedIDs []uint32) []uint32 {
+	index := buildReferrerIndex()
+	visited := make(map[uint32]bool, len(seedIDs))
+	for _, id := range seedIDs {
+		visited[id] = true
+	}
+	queue := append([]uint32{}, seedIDs...)
+	extra := make([]uint32, 0)
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, referrer := range index[id] {
+			if !visited[referrer] {
+				visited[referrer] = true
+				extra = append(extra, referrer)
+				queue = append(queue, referrer)
+			}
+		}
+	}
+	return extra
+}
+
 func ReadAllBTFType() []BTFType {
 	iter := reader.Iterate()
 	types := make([]BTFType, 0)
@@ -154,6 +462,52 @@ func ReadAllBTFType() []BTFType {
 	return types
 }
 
+func ReadAllBTFTypeByID() map[uint32]BTFType {
+	iter := reader.Iterate()
+	types := make(map[uint32]BTFType)
+	for iter.Next() {
+		btfType := BTFTypeParser(iter.Type)
+		if btfType == nil {
+			continue
+		}
+		id, _ := reader.TypeID(iter.Type)
+		types[uint32(id)] = btfType
+	}
+	return types
+}
+
+// DumpRawBTF re-serializes the types selected by ids (as produced by
+// WalkForTargetTypes or ReadAllBTFTypeByID) into a standalone BTF blob with a
+// rebuilt string table and remapped TypeIDs.
+func DumpRawBTF(ids map[uint32]BTFType) {
+	sortedIDs := make([]uint32, 0, len(ids))
+	for id := range ids {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Slice(sortedIDs, func(i, j int) bool { return sortedIDs[i] < sortedIDs[j] })
+
+	builder := &btf.Builder{}
+	for _, id := range sortedIDs {
+		t, err := reader.TypeByID(btf.TypeID(id))
+		if err != nil {
+			panic(err)
+		}
+		if _, err := builder.Add(t); err != nil {
+			panic(err)
+		}
+	}
+	raw, err := builder.Marshal(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	outputFile, err := os.Create(fileName + ".btf")
+	if err != nil {
+		panic(err)
+	}
+	defer outputFile.Close()
+	outputFile.Write(raw)
+}
+
 func GetTypeID(t btf.Type) uint32 {
 	if *isDereference {
 		t = btf.UnderlyingType(t)
@@ -190,6 +544,12 @@ func BTFTypeParser(t btf.Type) BTFType {
 	case *btf.Union:
 		return BTFUnionParser(btfType)
 	case *btf.Enum:
+		// BTF_KIND_ENUM64 unmarshals into the same *btf.Enum as a plain
+		// enum (cilium/ebpf has no separate Enum64 type); an 8-byte Size is
+		// the only signal that distinguishes it.
+		if btfType.Size == 8 {
+			return BTFEnum64Parser(btfType)
+		}
 		return BTFEnumParser(btfType)
 	case *btf.Fwd:
 		return BTFFwdParser(btfType)
@@ -212,7 +572,17 @@ func BTFTypeParser(t btf.Type) BTFType {
 	case *btf.Float:
 		return BTFFloatParser(btfType)
 	default:
-		panic(fmt.Errorf("unknown type %v", btfType))
+		// declTag/typeTag are unexported in cilium/ebpf, so they can't appear
+		// as a case above; fall back to the same reflection-based kind check
+		// already used for --verbose logging.
+		switch reflect.TypeOf(t).Elem().Name() {
+		case "declTag":
+			return BTFDeclTagParser(btfType)
+		case "typeTag":
+			return BTFTypeTagParser(btfType)
+		default:
+			panic(fmt.Errorf("unknown type %v", btfType))
+		}
 	}
 	return nil
 }
@@ -303,7 +673,7 @@ func (v *BTFArray) GetTypeName() string {
 }
 
 func (v *BTFArray) GetDependencies() []uint32 {
-	return []uint32{v.IndexType, v.IndexType}
+	return []uint32{v.IndexType, v.ElemType}
 }
 
 type BTFStruct struct {
@@ -318,8 +688,50 @@ type BTFStructMember struct {
 	Name         string `json:"name"`
 	Type         uint32 `json:"type"`
 	Offset       uint32 `json:"offset"`
+	OffsetBits   uint32 `json:"offset_bits"`
 	BitFieldSize uint32 `json:"bit_field_size"`
 	Size         int    `json:"size"`
+	SizeError    string `json:"size_error,omitempty"`
+}
+
+// expandMember converts a single btf.Member into one or more BTFStructMember
+// entries. baseOffsetBits is the bit offset of the enclosing member within
+// the top-level struct/union, so flattened members come out with absolute
+// offsets. When flatten is set, an anonymous nested struct/union member is
+// replaced by its own members instead of being emitted as a single opaque
+// field, matching how C code addresses them directly.
+func expandMember(member btf.Member, baseOffsetBits uint32, flatten bool) []*BTFStructMember {
+	offsetBits := baseOffsetBits + uint32(member.Offset)
+	if flatten && member.Name == "" && member.BitfieldSize == 0 {
+		switch nested := btf.UnderlyingType(member.Type).(type) {
+		case *btf.Struct:
+			return expandMembers(nested.Members, offsetBits, flatten)
+		case *btf.Union:
+			return expandMembers(nested.Members, offsetBits, flatten)
+		}
+	}
+	memberTypeID := GetTypeID(member.Type)
+	size, sizeErr := btf.Sizeof(member.Type)
+	btfMember := &BTFStructMember{
+		Name:         member.Name,
+		Type:         memberTypeID,
+		Offset:       offsetBits / 8,
+		OffsetBits:   offsetBits,
+		BitFieldSize: uint32(member.BitfieldSize),
+		Size:         size,
+	}
+	if sizeErr != nil {
+		btfMember.SizeError = sizeErr.Error()
+	}
+	return []*BTFStructMember{btfMember}
+}
+
+func expandMembers(rawMembers []btf.Member, baseOffsetBits uint32, flatten bool) []*BTFStructMember {
+	members := make([]*BTFStructMember, 0, len(rawMembers))
+	for _, member := range rawMembers {
+		members = append(members, expandMember(member, baseOffsetBits, flatten)...)
+	}
+	return members
 }
 
 func BTFStructParser(t *btf.Struct) *BTFStruct {
@@ -328,20 +740,10 @@ func BTFStructParser(t *btf.Struct) *BTFStruct {
 		Size:     t.Size,
 		Name:     t.Name,
 	}
-	membersMap := make(map[string]*BTFStructMember, len(t.Members))
-	members := make([]*BTFStructMember, 0, len(t.Members))
-	for _, member := range t.Members {
-		memberTypeID := GetTypeID(member.Type)
-		size, _ := btf.Sizeof(member.Type)
-		btfMember := &BTFStructMember{
-			Name:         member.Name,
-			Type:         memberTypeID,
-			Offset:       member.Offset.Bytes(),
-			BitFieldSize: uint32(member.BitfieldSize),
-			Size:         size,
-		}
-		membersMap[member.Name] = btfMember
-		members = append(members, btfMember)
+	members := expandMembers(t.Members, 0, *isFlattenAnon)
+	membersMap := make(map[string]*BTFStructMember, len(members))
+	for _, member := range members {
+		membersMap[member.Name] = member
 	}
 	if *isAsMap {
 		btfStruct.MembersMap = membersMap
@@ -384,20 +786,10 @@ func BTFUnionParser(t *btf.Union) *BTFUnion {
 		Size:     t.Size,
 		Name:     t.Name,
 	}
-	membersMap := make(map[string]*BTFStructMember, len(t.Members))
-	members := make([]*BTFStructMember, 0, len(t.Members))
-	for _, member := range t.Members {
-		memberTypeID := GetTypeID(member.Type)
-		size, _ := btf.Sizeof(member.Type)
-		btfMember := &BTFStructMember{
-			Name:         member.Name,
-			Type:         memberTypeID,
-			Offset:       member.Offset.Bytes(),
-			BitFieldSize: uint32(member.BitfieldSize),
-			Size:         size,
-		}
-		membersMap[member.Name] = btfMember
-		members = append(members, btfMember)
+	members := expandMembers(t.Members, 0, *isFlattenAnon)
+	membersMap := make(map[string]*BTFStructMember, len(members))
+	for _, member := range members {
+		membersMap[member.Name] = member
 	}
 	if *isAsMap {
 		btfUnion.MembersMap = membersMap
@@ -472,6 +864,52 @@ func (v *BTFEnum) GetDependencies() []uint32 {
 	return []uint32{}
 }
 
+type BTFEnum64 struct {
+	TypeName  string                   `json:"type_name"`
+	Name      string                   `json:"name"`
+	Size      uint32                   `json:"size"`
+	Signed    bool                     `json:"signed"`
+	ValuesMap map[uint64]*BTFEnumValue `json:"values_map,omitempty"`
+	Values    []*BTFEnumValue          `json:"values,omitempty"`
+}
+
+// BTFEnum64Parser takes the same *btf.Enum as BTFEnumParser - cilium/ebpf
+// unmarshals BTF_KIND_ENUM64 into *btf.Enum too, distinguished only by an
+// 8-byte Size - the caller (BTFTypeParser) picks this parser over
+// BTFEnumParser based on that Size.
+func BTFEnum64Parser(t *btf.Enum) *BTFEnum64 {
+	btfEnum64 := &BTFEnum64{
+		TypeName: "enum64",
+		Name:     t.Name,
+		Size:     t.Size,
+		Signed:   t.Signed,
+	}
+	valuesMap := make(map[uint64]*BTFEnumValue, len(t.Values))
+	values := make([]*BTFEnumValue, 0, len(t.Values))
+	for _, value := range t.Values {
+		btfValue := &BTFEnumValue{
+			Name:  value.Name,
+			Value: value.Value,
+		}
+		valuesMap[value.Value] = btfValue
+		values = append(values, btfValue)
+	}
+	if *isAsMap {
+		btfEnum64.ValuesMap = valuesMap
+	} else {
+		btfEnum64.Values = values
+	}
+	return btfEnum64
+}
+
+func (v *BTFEnum64) GetTypeName() string {
+	return v.TypeName
+}
+
+func (v *BTFEnum64) GetDependencies() []uint32 {
+	return []uint32{}
+}
+
 type BTFFwd struct {
 	TypeName string `json:"type_name"`
 	Name     string `json:"name"`
@@ -580,6 +1018,64 @@ func (v *BTFRestrict) GetDependencies() []uint32 {
 	return []uint32{v.Type}
 }
 
+type BTFDeclTag struct {
+	TypeName     string `json:"type_name"`
+	Name         string `json:"name"`
+	Type         uint32 `json:"type"`
+	ComponentIdx int    `json:"component_idx"`
+}
+
+// BTFDeclTagParser takes the generic btf.Type interface rather than the
+// concrete *btf.declTag because that type is unexported by cilium/ebpf; its
+// Value/Type/Index fields are still exported, so reflection can reach them.
+func BTFDeclTagParser(t btf.Type) *BTFDeclTag {
+	rv := reflect.ValueOf(t).Elem()
+	targetType, _ := rv.FieldByName("Type").Interface().(btf.Type)
+	typeID := GetTypeID(targetType)
+	return &BTFDeclTag{
+		TypeName:     "decl_tag",
+		Name:         rv.FieldByName("Value").String(),
+		Type:         typeID,
+		ComponentIdx: int(rv.FieldByName("Index").Int()),
+	}
+}
+
+func (v *BTFDeclTag) GetTypeName() string {
+	return v.TypeName
+}
+
+func (v *BTFDeclTag) GetDependencies() []uint32 {
+	return []uint32{v.Type}
+}
+
+type BTFTypeTag struct {
+	TypeName string `json:"type_name"`
+	Name     string `json:"name"`
+	Type     uint32 `json:"type"`
+}
+
+// BTFTypeTagParser takes the generic btf.Type interface rather than the
+// concrete *btf.typeTag because that type is unexported by cilium/ebpf; its
+// Value/Type fields are still exported, so reflection can reach them.
+func BTFTypeTagParser(t btf.Type) *BTFTypeTag {
+	rv := reflect.ValueOf(t).Elem()
+	targetType, _ := rv.FieldByName("Type").Interface().(btf.Type)
+	typeID := GetTypeID(targetType)
+	return &BTFTypeTag{
+		TypeName: "type_tag",
+		Name:     rv.FieldByName("Value").String(),
+		Type:     typeID,
+	}
+}
+
+func (v *BTFTypeTag) GetTypeName() string {
+	return v.TypeName
+}
+
+func (v *BTFTypeTag) GetDependencies() []uint32 {
+	return []uint32{v.Type}
+}
+
 type BTFFunc struct {
 	TypeName string `json:"type_name"`
 	Name     string `json:"name"`
@@ -739,3 +1235,728 @@ func (v *BTFFloat) GetTypeName() string {
 func (v *BTFFloat) GetDependencies() []uint32 {
 	return []uint32{}
 }
+
+// DumpCHeader walks the BTFType subgraph in ids and renders it as a
+// standalone, CO-RE relocatable C header (vmlinux.h style): all named
+// struct/union tags are forward-declared first so pointer cycles between
+// them resolve, then every type is emitted in dependency order so that
+// value-embedded members are always fully defined before use.
+func DumpCHeader(ids map[uint32]BTFType) {
+	order := cHeaderTopoOrder(ids)
+
+	var b strings.Builder
+	b.WriteString("/* Generated by BTF-Dumper --output-format=c-header */\n\n")
+
+	declared := make(map[uint32]bool, len(order))
+	for _, id := range order {
+		switch v := ids[id].(type) {
+		case *BTFStruct:
+			if v.Name != "" && !declared[id] {
+				b.WriteString(fmt.Sprintf("struct %s;\n", v.Name))
+				declared[id] = true
+			}
+		case *BTFUnion:
+			if v.Name != "" && !declared[id] {
+				b.WriteString(fmt.Sprintf("union %s;\n", v.Name))
+				declared[id] = true
+			}
+		}
+	}
+	b.WriteString("\n")
+
+	for _, id := range order {
+		emitTopLevelCType(&b, id, ids)
+	}
+
+	outputFile, err := os.Create(fileName + ".h")
+	if err != nil {
+		panic(err)
+	}
+	defer outputFile.Close()
+	outputFile.WriteString(b.String())
+}
+
+// cHeaderTopoOrder returns every struct/union/enum/typedef/func/var in ids,
+// ordered so that a type's value-embedded dependencies (struct/union/array
+// members held by value, not behind a pointer) are always emitted first.
+func cHeaderTopoOrder(ids map[uint32]BTFType) []uint32 {
+	nameable := make(map[uint32]bool, len(ids))
+	for id, t := range ids {
+		switch v := t.(type) {
+		case *BTFStruct:
+			// Anonymous structs have no top-level C spelling of their own;
+			// they're inlined wherever they're referenced (see typeDecl),
+			// never emitted as a standalone definition.
+			if v.Name != "" {
+				nameable[id] = true
+			}
+		case *BTFUnion:
+			if v.Name != "" {
+				nameable[id] = true
+			}
+		case *BTFEnum, *BTFEnum64, *BTFTypeDef, *BTFFunc, *BTFVar:
+			nameable[id] = true
+		}
+	}
+	sortedIDs := make([]uint32, 0, len(nameable))
+	for id := range nameable {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Slice(sortedIDs, func(i, j int) bool { return sortedIDs[i] < sortedIDs[j] })
+
+	visited := make(map[uint32]bool, len(nameable))
+	visiting := make(map[uint32]bool, len(nameable))
+	order := make([]uint32, 0, len(nameable))
+
+	var visit func(id uint32)
+	visit = func(id uint32) {
+		if visited[id] || visiting[id] {
+			return
+		}
+		visiting[id] = true
+		for _, dep := range topLevelValueDeps(id, ids) {
+			if nameable[dep] {
+				visit(dep)
+			}
+		}
+		visiting[id] = false
+		visited[id] = true
+		order = append(order, id)
+	}
+	for _, id := range sortedIDs {
+		visit(id)
+	}
+	return order
+}
+
+func topLevelValueDeps(id uint32, ids map[uint32]BTFType) []uint32 {
+	switch v := ids[id].(type) {
+	case *BTFStruct:
+		return memberValueDeps(structMembers(v), ids)
+	case *BTFUnion:
+		return memberValueDeps(unionMembers(v), ids)
+	case *BTFTypeDef:
+		return valueDepIDs(v.Type, ids)
+	case *BTFFunc:
+		if fp, ok := ids[v.Type].(*BTFFuncProto); ok {
+			deps := valueDepIDs(fp.Return, ids)
+			for _, p := range fp.Params {
+				deps = append(deps, valueDepIDs(p.Type, ids)...)
+			}
+			return deps
+		}
+	case *BTFVar:
+		return valueDepIDs(v.Type, ids)
+	}
+	return nil
+}
+
+func memberValueDeps(members []*BTFStructMember, ids map[uint32]BTFType) []uint32 {
+	deps := make([]uint32, 0, len(members))
+	for _, m := range members {
+		deps = append(deps, valueDepIDs(m.Type, ids)...)
+	}
+	return deps
+}
+
+// valueDepIDs follows qualifiers and arrays (which force their element to be
+// fully defined) but stops at pointers, which only ever need a forward
+// declaration.
+func valueDepIDs(id uint32, ids map[uint32]BTFType) []uint32 {
+	switch v := ids[id].(type) {
+	case *BTFStruct, *BTFUnion, *BTFEnum, *BTFEnum64, *BTFTypeDef:
+		return []uint32{id}
+	case *BTFArray:
+		return valueDepIDs(v.ElemType, ids)
+	case *BTFConst:
+		return valueDepIDs(v.Type, ids)
+	case *BTFVolatile:
+		return valueDepIDs(v.Type, ids)
+	case *BTFRestrict:
+		return valueDepIDs(v.Type, ids)
+	case *BTFDeclTag:
+		return valueDepIDs(v.Type, ids)
+	case *BTFTypeTag:
+		return valueDepIDs(v.Type, ids)
+	default:
+		return nil
+	}
+}
+
+func emitTopLevelCType(b *strings.Builder, id uint32, ids map[uint32]BTFType) {
+	switch v := ids[id].(type) {
+	case *BTFStruct:
+		renderAggregateBody(b, "", "struct", v.Name, structMembers(v), ids)
+		b.WriteString(" __attribute__((preserve_access_index));\n\n")
+	case *BTFUnion:
+		renderAggregateBody(b, "", "union", v.Name, unionMembers(v), ids)
+		b.WriteString(" __attribute__((preserve_access_index));\n\n")
+	case *BTFEnum:
+		b.WriteString(renderEnumBody(v.Name, enumValues(v)))
+	case *BTFEnum64:
+		b.WriteString(renderEnumBody(v.Name, enumValues64(v)))
+	case *BTFTypeDef:
+		b.WriteString("typedef " + typeDecl(v.Type, ids, v.Name) + ";\n\n")
+	case *BTFFunc:
+		b.WriteString(typeDecl(v.Type, ids, v.Name) + ";\n\n")
+	case *BTFVar:
+		b.WriteString("extern " + typeDecl(v.Type, ids, v.Name) + ";\n\n")
+	}
+}
+
+// inlineAggDecl renders an anonymous struct/union's full body in place of a
+// bare tag reference, so a typedef/var/func target (or a pointer/array of
+// one) whose type has no tag name of its own still gets defined - eg.
+// "typedef struct { int counter; } atomic_t;" instead of the member name
+// being mistaken for a tag and the body left dangling, undefined, elsewhere.
+func inlineAggDecl(kind string, members []*BTFStructMember, ids map[uint32]BTFType, name string) string {
+	var b strings.Builder
+	renderAggregateBody(&b, "", kind, "", members, ids)
+	return joinDecl(b.String(), name)
+}
+
+func renderAggregateBody(b *strings.Builder, indent string, kind string, name string, members []*BTFStructMember, ids map[uint32]BTFType) {
+	b.WriteString(aggTag(kind, name) + " {\n")
+	for _, m := range members {
+		writeCMember(b, indent+"\t", m, ids)
+	}
+	b.WriteString(indent + "}")
+}
+
+// writeCMember inlines anonymous nested structs/unions directly into the
+// parent, matching how C actually declares them.
+func writeCMember(b *strings.Builder, indent string, m *BTFStructMember, ids map[uint32]BTFType) {
+	switch resolved := stripQualifiers(m.Type, ids).(type) {
+	case *BTFStruct:
+		if resolved.Name == "" {
+			b.WriteString(indent)
+			renderAggregateBody(b, indent, "struct", "", structMembers(resolved), ids)
+			writeCMemberTail(b, m)
+			return
+		}
+	case *BTFUnion:
+		if resolved.Name == "" {
+			b.WriteString(indent)
+			renderAggregateBody(b, indent, "union", "", unionMembers(resolved), ids)
+			writeCMemberTail(b, m)
+			return
+		}
+	}
+	decl := typeDecl(m.Type, ids, m.Name)
+	if m.BitFieldSize != 0 {
+		decl = fmt.Sprintf("%s : %d", decl, m.BitFieldSize)
+	}
+	b.WriteString(indent + decl + ";\n")
+}
+
+func writeCMemberTail(b *strings.Builder, m *BTFStructMember) {
+	if m.Name != "" {
+		b.WriteString(" " + m.Name)
+	}
+	if m.BitFieldSize != 0 {
+		b.WriteString(fmt.Sprintf(" : %d", m.BitFieldSize))
+	}
+	b.WriteString(";\n")
+}
+
+func renderEnumBody(name string, values []*BTFEnumValue) string {
+	var b strings.Builder
+	b.WriteString(aggTag("enum", name) + " {\n")
+	for _, val := range values {
+		b.WriteString(fmt.Sprintf("\t%s = %d,\n", val.Name, val.Value))
+	}
+	b.WriteString("};\n\n")
+	return b.String()
+}
+
+func stripQualifiers(id uint32, ids map[uint32]BTFType) BTFType {
+	switch v := ids[id].(type) {
+	case *BTFConst:
+		return stripQualifiers(v.Type, ids)
+	case *BTFVolatile:
+		return stripQualifiers(v.Type, ids)
+	case *BTFRestrict:
+		return stripQualifiers(v.Type, ids)
+	case *BTFDeclTag:
+		return stripQualifiers(v.Type, ids)
+	case *BTFTypeTag:
+		return stripQualifiers(v.Type, ids)
+	default:
+		return v
+	}
+}
+
+func structMembers(v *BTFStruct) []*BTFStructMember {
+	if len(v.Members) > 0 || v.MembersMap == nil {
+		return v.Members
+	}
+	return sortedStructMembers(v.MembersMap)
+}
+
+func unionMembers(v *BTFUnion) []*BTFStructMember {
+	if len(v.Members) > 0 || v.MembersMap == nil {
+		return v.Members
+	}
+	return sortedStructMembers(v.MembersMap)
+}
+
+func sortedStructMembers(m map[string]*BTFStructMember) []*BTFStructMember {
+	out := make([]*BTFStructMember, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+func enumValues(v *BTFEnum) []*BTFEnumValue {
+	if len(v.Values) > 0 || v.ValuesMap == nil {
+		return v.Values
+	}
+	return sortedEnumValues(v.ValuesMap)
+}
+
+func enumValues64(v *BTFEnum64) []*BTFEnumValue {
+	if len(v.Values) > 0 || v.ValuesMap == nil {
+		return v.Values
+	}
+	return sortedEnumValues(v.ValuesMap)
+}
+
+func sortedEnumValues(m map[uint64]*BTFEnumValue) []*BTFEnumValue {
+	out := make([]*BTFEnumValue, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value < out[j].Value })
+	return out
+}
+
+// typeDecl renders the C declaration of a variable/field called name whose
+// type is id, following the usual right-left declarator rule so pointers,
+// arrays and function pointers combine correctly (eg. "int (*name)(void)").
+func typeDecl(id uint32, ids map[uint32]BTFType, name string) string {
+	t, ok := ids[id]
+	if !ok {
+		return joinDecl("void", name)
+	}
+	switch v := t.(type) {
+	case *BTFVoid:
+		return joinDecl("void", name)
+	case *BTFInt:
+		return joinDecl(v.Name, name)
+	case *BTFFloat:
+		return joinDecl(v.Name, name)
+	case *BTFFwd:
+		return joinDecl(v.Kind+" "+v.Name, name)
+	case *BTFStruct:
+		if v.Name == "" {
+			return inlineAggDecl("struct", structMembers(v), ids, name)
+		}
+		return joinDecl(aggTag("struct", v.Name), name)
+	case *BTFUnion:
+		if v.Name == "" {
+			return inlineAggDecl("union", unionMembers(v), ids, name)
+		}
+		return joinDecl(aggTag("union", v.Name), name)
+	case *BTFEnum:
+		return joinDecl(aggTag("enum", v.Name), name)
+	case *BTFEnum64:
+		return joinDecl(aggTag("enum", v.Name), name)
+	case *BTFTypeDef:
+		return joinDecl(v.Name, name)
+	case *BTFConst:
+		return "const " + typeDecl(v.Type, ids, name)
+	case *BTFVolatile:
+		return "volatile " + typeDecl(v.Type, ids, name)
+	case *BTFRestrict:
+		return typeDecl(v.Type, ids, name)
+	case *BTFDeclTag:
+		return typeDecl(v.Type, ids, name)
+	case *BTFTypeTag:
+		return typeDecl(v.Type, ids, name)
+	case *BTFArray:
+		return typeDecl(v.ElemType, ids, fmt.Sprintf("%s[%d]", name, v.Count))
+	case *BTFPointer:
+		ptrName := "*" + name
+		switch ids[v.TargetType].(type) {
+		case *BTFArray, *BTFFuncProto:
+			ptrName = "(*" + name + ")"
+		}
+		return typeDecl(v.TargetType, ids, ptrName)
+	case *BTFFuncProto:
+		params := make([]string, 0, len(v.Params))
+		for i, p := range v.Params {
+			pname := p.Name
+			if pname == "" {
+				pname = fmt.Sprintf("arg%d", i+1)
+			}
+			params = append(params, typeDecl(p.Type, ids, pname))
+		}
+		if len(params) == 0 {
+			params = append(params, "void")
+		}
+		return typeDecl(v.Return, ids, fmt.Sprintf("%s(%s)", name, strings.Join(params, ", ")))
+	default:
+		return joinDecl("void", name)
+	}
+}
+
+func aggTag(kind string, name string) string {
+	if name == "" {
+		return kind
+	}
+	return kind + " " + name
+}
+
+func joinDecl(typeStr string, name string) string {
+	if name == "" {
+		return typeStr
+	}
+	return typeStr + " " + name
+}
+
+// readAllTypesForSpec parses every type in spec into BTFType, temporarily
+// pointing the package-global reader at it so GetTypeID and BTFTypeParser
+// resolve IDs against the right spec.
+func readAllTypesForSpec(spec *btf.Spec) map[uint32]BTFType {
+	saved := reader
+	reader = spec
+	defer func() { reader = saved }()
+	return ReadAllBTFTypeByID()
+}
+
+type BTFDiffEntry struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+type diffKey struct {
+	kind string
+	name string
+}
+
+// essentialName strips the CO-RE "___suffix" convention (eg. "event___v2")
+// so that types renamed across kernel versions to disambiguate duplicate
+// names still match.
+func essentialName(name string) string {
+	if idx := strings.Index(name, "___"); idx >= 0 {
+		return name[:idx]
+	}
+	return name
+}
+
+// diffIdentity returns the (kind, name) a type is matched by across specs.
+// struct, union, enum and enum64 names are reduced to their essential name;
+// typedef, func, var and fwd keep their full name. Anonymous types have no
+// stable identity and are skipped.
+func diffIdentity(t BTFType) (kind string, name string, ok bool) {
+	switch v := t.(type) {
+	case *BTFStruct:
+		if v.Name == "" {
+			return "", "", false
+		}
+		return "struct", essentialName(v.Name), true
+	case *BTFUnion:
+		if v.Name == "" {
+			return "", "", false
+		}
+		return "union", essentialName(v.Name), true
+	case *BTFEnum:
+		if v.Name == "" {
+			return "", "", false
+		}
+		return "enum", essentialName(v.Name), true
+	case *BTFEnum64:
+		if v.Name == "" {
+			return "", "", false
+		}
+		return "enum64", essentialName(v.Name), true
+	case *BTFTypeDef:
+		return "typedef", v.Name, true
+	case *BTFFunc:
+		return "func", v.Name, true
+	case *BTFVar:
+		return "var", v.Name, true
+	case *BTFFwd:
+		if v.Name == "" {
+			return "", "", false
+		}
+		return "fwd", v.Name, true
+	default:
+		return "", "", false
+	}
+}
+
+func indexByDiffKey(ids map[uint32]BTFType) map[diffKey]BTFType {
+	out := make(map[diffKey]BTFType, len(ids))
+	winner := make(map[diffKey]uint32, len(ids))
+	for id, t := range ids {
+		kind, name, ok := diffIdentity(t)
+		if !ok {
+			continue
+		}
+		key := diffKey{kind, name}
+		// ids is a Go map, so iteration order is randomized; break ties on the
+		// lowest TypeID so a collision between two distinct types sharing a
+		// kind+essential-name resolves the same way on every run.
+		if prevID, seen := winner[key]; seen && prevID <= id {
+			continue
+		}
+		winner[key] = id
+		out[key] = t
+	}
+	return out
+}
+
+// computeDiff matches types across oldIDs and newIDs by kind + name as given
+// by diffIdentity (TypeIDs are not stable across kernel builds) and reports additions,
+// removals and field-level changes for everything that matched.
+func computeDiff(oldIDs, newIDs map[uint32]BTFType) []*BTFDiffEntry {
+	oldByKey := indexByDiffKey(oldIDs)
+	newByKey := indexByDiffKey(newIDs)
+
+	keySet := make(map[diffKey]bool, len(oldByKey)+len(newByKey))
+	for k := range oldByKey {
+		keySet[k] = true
+	}
+	for k := range newByKey {
+		keySet[k] = true
+	}
+	keys := make([]diffKey, 0, len(keySet))
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	entries := make([]*BTFDiffEntry, 0, len(keys))
+	for _, k := range keys {
+		o, oldOK := oldByKey[k]
+		n, newOK := newByKey[k]
+		switch {
+		case oldOK && !newOK:
+			entries = append(entries, &BTFDiffEntry{Kind: k.kind, Name: k.name, Status: "removed"})
+		case !oldOK && newOK:
+			entries = append(entries, &BTFDiffEntry{Kind: k.kind, Name: k.name, Status: "added"})
+		default:
+			if changes := diffTypeBody(k.kind, o, n, oldIDs, newIDs); len(changes) > 0 {
+				entries = append(entries, &BTFDiffEntry{Kind: k.kind, Name: k.name, Status: "changed", Changes: changes})
+			}
+		}
+	}
+	return entries
+}
+
+func diffTypeBody(kind string, o, n BTFType, oldIDs, newIDs map[uint32]BTFType) []string {
+	changes := make([]string, 0)
+	switch kind {
+	case "struct":
+		os_, ns_ := o.(*BTFStruct), n.(*BTFStruct)
+		if os_.Size != ns_.Size {
+			changes = append(changes, fmt.Sprintf("size changed from %d to %d", os_.Size, ns_.Size))
+		}
+		changes = append(changes, diffMembers(structMembers(os_), structMembers(ns_), oldIDs, newIDs, 0)...)
+	case "union":
+		ou, nu := o.(*BTFUnion), n.(*BTFUnion)
+		if ou.Size != nu.Size {
+			changes = append(changes, fmt.Sprintf("size changed from %d to %d", ou.Size, nu.Size))
+		}
+		changes = append(changes, diffMembers(unionMembers(ou), unionMembers(nu), oldIDs, newIDs, 0)...)
+	case "enum":
+		oe, ne := o.(*BTFEnum), n.(*BTFEnum)
+		changes = append(changes, diffEnumHeader(oe.Size, ne.Size, oe.Signed, ne.Signed)...)
+		changes = append(changes, diffEnumValues(enumValues(oe), enumValues(ne))...)
+	case "enum64":
+		oe, ne := o.(*BTFEnum64), n.(*BTFEnum64)
+		changes = append(changes, diffEnumHeader(oe.Size, ne.Size, oe.Signed, ne.Signed)...)
+		changes = append(changes, diffEnumValues(enumValues64(oe), enumValues64(ne))...)
+	case "typedef":
+		ot, nt := o.(*BTFTypeDef), n.(*BTFTypeDef)
+		oldTarget, newTarget := typeDecl(ot.Type, oldIDs, ""), typeDecl(nt.Type, newIDs, "")
+		if oldTarget != newTarget {
+			changes = append(changes, fmt.Sprintf("target changed from %q to %q", oldTarget, newTarget))
+		}
+	case "func":
+		of, nf := o.(*BTFFunc), n.(*BTFFunc)
+		if of.Linkage != nf.Linkage {
+			changes = append(changes, fmt.Sprintf("linkage changed from %q to %q", of.Linkage, nf.Linkage))
+		}
+		oldSig, newSig := typeDecl(of.Type, oldIDs, ""), typeDecl(nf.Type, newIDs, "")
+		if oldSig != newSig {
+			changes = append(changes, fmt.Sprintf("signature changed from %q to %q", oldSig, newSig))
+		}
+	case "var":
+		ov, nv := o.(*BTFVar), n.(*BTFVar)
+		if ov.Linkage != nv.Linkage {
+			changes = append(changes, fmt.Sprintf("linkage changed from %q to %q", ov.Linkage, nv.Linkage))
+		}
+		oldType, newType := typeDecl(ov.Type, oldIDs, ""), typeDecl(nv.Type, newIDs, "")
+		if oldType != newType {
+			changes = append(changes, fmt.Sprintf("type changed from %q to %q", oldType, newType))
+		}
+	case "fwd":
+		of, nf := o.(*BTFFwd), n.(*BTFFwd)
+		if of.Kind != nf.Kind {
+			changes = append(changes, fmt.Sprintf("kind changed from %q to %q", of.Kind, nf.Kind))
+		}
+	}
+	return changes
+}
+
+func diffEnumHeader(oldSize, newSize uint32, oldSigned, newSigned bool) []string {
+	changes := make([]string, 0)
+	if oldSize != newSize {
+		changes = append(changes, fmt.Sprintf("size changed from %d to %d", oldSize, newSize))
+	}
+	if oldSigned != newSigned {
+		changes = append(changes, fmt.Sprintf("signedness changed from %v to %v", oldSigned, newSigned))
+	}
+	return changes
+}
+
+func diffEnumValues(oldValues, newValues []*BTFEnumValue) []string {
+	oldByName := make(map[string]uint64, len(oldValues))
+	for _, v := range oldValues {
+		oldByName[v.Name] = v.Value
+	}
+	newByName := make(map[string]uint64, len(newValues))
+	for _, v := range newValues {
+		newByName[v.Name] = v.Value
+	}
+	changes := make([]string, 0)
+	for name, ov := range oldByName {
+		nv, ok := newByName[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("value %q removed", name))
+			continue
+		}
+		if ov != nv {
+			changes = append(changes, fmt.Sprintf("value %q changed from %d to %d", name, ov, nv))
+		}
+	}
+	for name := range newByName {
+		if _, ok := oldByName[name]; !ok {
+			changes = append(changes, fmt.Sprintf("value %q added", name))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// diffMembers compares two member lists by name, reporting additions,
+// removals, reorders and offset/type/bitfield changes. Anonymous nested
+// structs/unions have no name to match by, so they are recursed into
+// directly; depth bounds that recursion so a malformed cyclic blob can't
+// hang the diff.
+func diffMembers(oldMembers, newMembers []*BTFStructMember, oldIDs, newIDs map[uint32]BTFType, depth int) []string {
+	oldByName := make(map[string]*BTFStructMember, len(oldMembers))
+	oldPos := make(map[string]int, len(oldMembers))
+	for i, m := range oldMembers {
+		key := memberKey(m, i)
+		oldByName[key] = m
+		oldPos[key] = i
+	}
+	newByName := make(map[string]*BTFStructMember, len(newMembers))
+	newPos := make(map[string]int, len(newMembers))
+	for i, m := range newMembers {
+		key := memberKey(m, i)
+		newByName[key] = m
+		newPos[key] = i
+	}
+
+	changes := make([]string, 0)
+	for key, om := range oldByName {
+		nm, ok := newByName[key]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("member %q removed", key))
+			continue
+		}
+		if oldPos[key] != newPos[key] {
+			changes = append(changes, fmt.Sprintf("member %q moved from position %d to %d", key, oldPos[key], newPos[key]))
+		}
+		if om.Offset != nm.Offset {
+			changes = append(changes, fmt.Sprintf("member %q offset changed from %d to %d", key, om.Offset, nm.Offset))
+		}
+		if om.BitFieldSize != nm.BitFieldSize {
+			changes = append(changes, fmt.Sprintf("member %q bitfield size changed from %d to %d", key, om.BitFieldSize, nm.BitFieldSize))
+		}
+		oldAnon, oldIsAnon := anonymousAggMembers(om.Type, oldIDs)
+		newAnon, newIsAnon := anonymousAggMembers(nm.Type, newIDs)
+		sameAnonKind := oldIsAnon && newIsAnon &&
+			reflect.TypeOf(stripQualifiers(om.Type, oldIDs)) == reflect.TypeOf(stripQualifiers(nm.Type, newIDs))
+		oldType, newType := typeDecl(om.Type, oldIDs, ""), typeDecl(nm.Type, newIDs, "")
+		if sameAnonKind && depth < 4 {
+			for _, c := range diffMembers(oldAnon, newAnon, oldIDs, newIDs, depth+1) {
+				changes = append(changes, fmt.Sprintf("member %q: %s", key, c))
+			}
+		} else if oldType != newType {
+			changes = append(changes, fmt.Sprintf("member %q type changed from %q to %q", key, oldType, newType))
+		}
+	}
+	for key := range newByName {
+		if _, ok := oldByName[key]; !ok {
+			changes = append(changes, fmt.Sprintf("member %q added", key))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func memberKey(m *BTFStructMember, i int) string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return fmt.Sprintf("<anonymous#%d>", i)
+}
+
+func anonymousAggMembers(id uint32, ids map[uint32]BTFType) ([]*BTFStructMember, bool) {
+	switch v := stripQualifiers(id, ids).(type) {
+	case *BTFStruct:
+		if v.Name == "" {
+			return structMembers(v), true
+		}
+	case *BTFUnion:
+		if v.Name == "" {
+			return unionMembers(v), true
+		}
+	}
+	return nil, false
+}
+
+// DumpDiff writes entries as a machine-readable JSON report (diff-format=json)
+// or prints a human-readable summary (diff-format=human).
+func DumpDiff(entries []*BTFDiffEntry) {
+	if *diffFormat == "json" {
+		outputFile, err := os.Create(fileName + ".diff.json")
+		if err != nil {
+			panic(err)
+		}
+		defer outputFile.Close()
+		output, err := json.Marshal(entries)
+		if err != nil {
+			panic(err)
+		}
+		outputFile.Write(output)
+		return
+	}
+	for _, e := range entries {
+		switch e.Status {
+		case "added":
+			fmt.Printf("+ %s %s\n", e.Kind, e.Name)
+		case "removed":
+			fmt.Printf("- %s %s\n", e.Kind, e.Name)
+		case "changed":
+			fmt.Printf("~ %s %s\n", e.Kind, e.Name)
+			for _, c := range e.Changes {
+				fmt.Printf("    %s\n", c)
+			}
+		}
+	}
+}